@@ -1,26 +1,90 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"fmt"
 	"log"
 	"os"
-	"regexp"
-	"strings"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/buth/ecr-watch/metrics"
+	"github.com/buth/ecr-watch/notifier"
+	"github.com/buth/ecr-watch/watcher"
 	"github.com/kelseyhightower/envconfig"
 )
 
 var config struct {
-	AWSProfile string        `envconfig:"AWS_PROFILE"`
-	AWSRegion  string        `envconfig:"AWS_REGION" default:"us-east-1"`
-	Repository string        `required:"true"`
-	TagPattern string        `envconfig:"TAG_PATTERN" default:"^latest$" required:"true"`
-	Interval   time.Duration `required:"true" default:"30s" required:"true"`
+	AWSProfile      string        `envconfig:"AWS_PROFILE"`
+	AWSRegion       string        `envconfig:"AWS_REGION" default:"us-east-1"`
+	Repository      string        `envconfig:"REPOSITORY"`
+	TagPattern      string        `envconfig:"TAG_PATTERN" default:"^latest$"`
+	Interval        time.Duration `envconfig:"INTERVAL" default:"30s"`
+	Watch           bool          `envconfig:"WATCH" default:"false"`
+	StateFile       string        `envconfig:"STATE_FILE"`
+	ConfigFile      string        `envconfig:"CONFIG_FILE"`
+	TagStrategy     string        `envconfig:"TAG_STRATEGY" default:"pushed_at"`
+	SemverPattern   string        `envconfig:"SEMVER_PATTERN"`
+	AllowPrerelease bool          `envconfig:"ALLOW_PRERELEASE" default:"false"`
+	NotifyURL       string        `envconfig:"NOTIFY_URL"`
+	NotifyCommand   string        `envconfig:"NOTIFY_COMMAND"`
+	NotifyFile      string        `envconfig:"NOTIFY_FILE"`
+	NotifySNSTopic  string        `envconfig:"NOTIFY_SNS_TOPIC_ARN"`
+	NotifyFormat    string        `envconfig:"NOTIFY_FORMAT" default:"text"`
+	MetricsAddr     string        `envconfig:"METRICS_ADDR"`
+}
+
+// buildNotifier assembles the configured notification sinks into a single
+// notifier.Notifier, falling back to the original stdout behavior when none
+// of the NOTIFY_* settings are set.
+func buildNotifier(awsSession *session.Session) notifier.Notifier {
+	var ns notifier.Multi
+	if config.NotifyURL != "" {
+		ns = append(ns, notifier.NewWebhook(config.NotifyURL))
+	}
+	if config.NotifyCommand != "" {
+		ns = append(ns, notifier.NewExec(config.NotifyCommand, config.NotifyFormat))
+	}
+	if config.NotifyFile != "" {
+		ns = append(ns, notifier.NewFile(config.NotifyFile, config.NotifyFormat))
+	}
+	if config.NotifySNSTopic != "" {
+		ns = append(ns, notifier.NewSNS(awsSession, config.NotifySNSTopic))
+	}
+
+	if len(ns) == 0 {
+		return &notifier.Stdout{Writer: os.Stdout}
+	}
+	return ns
+}
+
+// loadRepositories returns the repositories to watch, either from
+// CONFIG_FILE or, for backward compatibility, from the single-repository
+// REPOSITORY/TAG_PATTERN/INTERVAL/STATE_FILE environment variables.
+func loadRepositories() ([]watcher.Repository, error) {
+	if config.ConfigFile != "" {
+		fileConfig, err := watcher.LoadFileConfig(config.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		return fileConfig.Repositories, nil
+	}
+
+	return []watcher.Repository{{
+		Name:            config.Repository,
+		TagPattern:      config.TagPattern,
+		Interval:        watcher.Duration(config.Interval),
+		StateFile:       config.StateFile,
+		TagStrategy:     config.TagStrategy,
+		SemverPattern:   config.SemverPattern,
+		AllowPrerelease: config.AllowPrerelease,
+	}}, nil
 }
 
 func main() {
@@ -37,7 +101,15 @@ func main() {
 		logger.Fatal(err)
 	}
 
-	tagRegexp, err := regexp.Compile(config.TagPattern)
+	if config.ConfigFile == "" && config.Repository == "" {
+		logger.Fatal("REPOSITORY or CONFIG_FILE is required")
+	}
+
+	if err := notifier.ValidateFormat(config.NotifyFormat); err != nil {
+		logger.Fatal(err)
+	}
+
+	repositories, err := loadRepositories()
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -45,7 +117,7 @@ func main() {
 	awsSessionOptions := session.Options{
 		Profile: config.AWSProfile,
 		Config: aws.Config{
-			Region: aws.String(config.AWSRegion),
+			Region:                        aws.String(config.AWSRegion),
 			CredentialsChainVerboseErrors: aws.Bool(true),
 		},
 	}
@@ -55,63 +127,40 @@ func main() {
 		logger.Fatal(err)
 	}
 
-	logger.Println("running")
-	ecrClient := ecr.New(awsSession)
-	mostRecentImagePushedAt := time.Time{}
-	for {
-		imageIDs := []*ecr.ImageIdentifier{}
-		listImagesInput := &ecr.ListImagesInput{
-			RepositoryName: aws.String(config.Repository),
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		for {
-			listImagesOutput, err := ecrClient.ListImages(listImagesInput)
-			if err != nil {
-				logger.Fatal(err)
-			}
-
-			for _, imageID := range listImagesOutput.ImageIds {
-				if tag := aws.StringValue(imageID.ImageTag); tagRegexp.MatchString(tag) {
-					logger.Printf("matched tag: %s", tag)
-					imageIDs = append(imageIDs, imageID)
-				}
-			}
+	callerIdentity, err := sts.New(awsSession).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		logger.Fatal(err)
+	}
 
-			if nextToken := listImagesOutput.NextToken; nextToken != nil {
-				listImagesInput.NextToken = nextToken
-			} else {
-				break
-			}
-		}
+	w := &watcher.Watcher{
+		ECRClient: ecr.New(awsSession),
+		Notifier:  buildNotifier(awsSession),
+		AccountID: aws.StringValue(callerIdentity.Account),
+		Region:    config.AWSRegion,
+		Watch:     config.Watch,
+		Logger:    logger,
+	}
 
-		describeImagesInput := &ecr.DescribeImagesInput{
-			RepositoryName: aws.String(config.Repository),
-			ImageIds:       imageIDs,
-		}
+	if config.MetricsAddr != "" {
+		go func() {
+			logger.Fatal(metrics.ListenAndServe(config.MetricsAddr))
+		}()
+	}
 
-		describeImagesOutput, err := ecrClient.DescribeImages(describeImagesInput)
-		if err != nil {
-			logger.Fatal(err)
-		}
+	logger.Println("running")
 
-		currentMostRecentImagePushedAt := time.Time{}
-		currentMostRecentImageTags := []string{}
-		for _, imageDetail := range describeImagesOutput.ImageDetails {
-			if imagePushedAt := aws.TimeValue(imageDetail.ImagePushedAt); imagePushedAt.After(currentMostRecentImagePushedAt) {
-				currentMostRecentImagePushedAt = imagePushedAt
-				currentMostRecentImageTags = aws.StringValueSlice(imageDetail.ImageTags)
+	var waitGroup sync.WaitGroup
+	for _, repo := range repositories {
+		waitGroup.Add(1)
+		go func(repo watcher.Repository) {
+			defer waitGroup.Done()
+			if err := w.Run(ctx, repo); err != nil {
+				logger.Fatal(err)
 			}
-		}
-
-		if !mostRecentImagePushedAt.IsZero() && currentMostRecentImagePushedAt.After(mostRecentImagePushedAt) {
-			logger.Println("exiting")
-			fmt.Print(strings.Join(currentMostRecentImageTags, ","))
-			return
-		}
-
-		mostRecentImagePushedAt = currentMostRecentImagePushedAt
-		logger.Printf("most recent image pushed at %s", mostRecentImagePushedAt)
-		logger.Printf("sleeping for %s", config.Interval)
-		time.Sleep(config.Interval)
+		}(repo)
 	}
+	waitGroup.Wait()
 }
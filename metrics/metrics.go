@@ -0,0 +1,60 @@
+// Package metrics exposes ecr-watch's poll health and image-lag Prometheus
+// metrics, served optionally so the zero-config CLI behavior is unchanged
+// when METRICS_ADDR isn't set.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// PollTotal counts each poll of a repository, labeled by its outcome.
+	PollTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ecr_watch_poll_total",
+		Help: "Total number of ECR polls, by repository and result.",
+	}, []string{"repo", "result"})
+
+	// PollDuration observes how long a single poll (ListImages +
+	// DescribeImages) took.
+	PollDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ecr_watch_poll_duration_seconds",
+		Help:    "Duration of a single ECR poll, by repository.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"repo"})
+
+	// APIThrottledTotal counts ECR API calls that failed with a throttling
+	// error, by repository.
+	APIThrottledTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ecr_watch_api_throttled_total",
+		Help: "Total number of ECR API calls that were throttled, by repository.",
+	}, []string{"repo"})
+
+	// ImageAgeSeconds reports how old the currently-latest matching image
+	// is, i.e. now minus its ImagePushedAt.
+	ImageAgeSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ecr_watch_image_age_seconds",
+		Help: "Age in seconds of the currently-latest matching image, by repository and tag.",
+	}, []string{"repo", "tag"})
+
+	// LastChangeTimestamp records the Unix time of the last notified
+	// change, by repository.
+	LastChangeTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ecr_watch_last_change_timestamp",
+		Help: "Unix timestamp of the last change notified for a repository.",
+	}, []string{"repo"})
+)
+
+func init() {
+	prometheus.MustRegister(PollTotal, PollDuration, APIThrottledTotal, ImageAgeSeconds, LastChangeTimestamp)
+}
+
+// ListenAndServe starts a /metrics HTTP server on addr. It blocks until the
+// server stops, so callers typically run it in its own goroutine.
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return http.ListenAndServe(addr, mux)
+}
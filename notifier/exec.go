@@ -0,0 +1,47 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Exec runs a shell command for each event, writing the formatted payload
+// to its stdin and passing the individual fields as ECR_WATCH_* environment
+// variables so simple scripts don't need a JSON parser.
+type Exec struct {
+	Command string
+	Format  string
+}
+
+// NewExec returns an Exec notifier that runs command via "sh -c".
+func NewExec(command, format string) *Exec {
+	return &Exec{Command: command, Format: format}
+}
+
+func (e *Exec) Notify(ctx context.Context, event ImageEvent) error {
+	body, err := Format(event, e.Format)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", e.Command)
+	cmd.Stdin = bytes.NewBufferString(body)
+	cmd.Env = append(cmd.Environ(),
+		"ECR_WATCH_REPOSITORY="+event.Repository,
+		"ECR_WATCH_TAGS="+strings.Join(event.Tags, ","),
+		"ECR_WATCH_DIGEST="+event.Digest,
+		"ECR_WATCH_IMAGE_PUSHED_AT="+event.ImagePushedAt.UTC().Format("2006-01-02T15:04:05Z07:00"),
+		"ECR_WATCH_IMAGE_SIZE_BYTES="+strconv.FormatInt(event.ImageSizeInBytes, 10),
+		"ECR_WATCH_ACCOUNT_ID="+event.AccountID,
+		"ECR_WATCH_REGION="+event.Region,
+	)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("notifier: exec %q failed: %w: %s", e.Command, err, out)
+	}
+	return nil
+}
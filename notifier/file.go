@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"context"
+	"os"
+)
+
+// File appends one formatted line per event to Path, creating it if
+// necessary. It's meant for simple tailing/log-shipping setups where a
+// webhook or exec sink would be overkill.
+type File struct {
+	Path   string
+	Format string
+}
+
+// NewFile returns a File notifier appending to path.
+func NewFile(path, format string) *File {
+	return &File{Path: path, Format: format}
+}
+
+func (f *File) Notify(ctx context.Context, event ImageEvent) error {
+	body, err := Format(event, f.Format)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(body + "\n")
+	return err
+}
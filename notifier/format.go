@@ -0,0 +1,62 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// payload is the wire representation of an ImageEvent shared by every
+// built-in sink, keyed to match the field names called out in the ECR
+// console and CLI (repository, imagePushedAt, imageSizeInBytes, ...).
+type payload struct {
+	Repository       string   `json:"repository"`
+	Tags             []string `json:"tags"`
+	Digest           string   `json:"digest"`
+	ImagePushedAt    string   `json:"imagePushedAt"`
+	ImageSizeInBytes int64    `json:"imageSizeInBytes"`
+	AccountID        string   `json:"accountId"`
+	Region           string   `json:"region"`
+}
+
+// ValidateFormat reports an error if format isn't a format Format knows how
+// to render. Callers that accept a format at startup (e.g. NOTIFY_FORMAT)
+// should call this eagerly, since Format itself isn't reached until the
+// first notification fires.
+func ValidateFormat(format string) error {
+	switch format {
+	case "", "text", "json":
+		return nil
+	default:
+		return fmt.Errorf("notifier: unknown format %q", format)
+	}
+}
+
+// Format renders event as "json" or "text". An unrecognized format is
+// rejected with an error rather than silently falling back; ValidateFormat
+// applies the same check so it can be made at startup.
+func Format(event ImageEvent, format string) (string, error) {
+	p := payload{
+		Repository:       event.Repository,
+		Tags:             event.Tags,
+		Digest:           event.Digest,
+		ImagePushedAt:    event.ImagePushedAt.UTC().Format("2006-01-02T15:04:05Z07:00"),
+		ImageSizeInBytes: event.ImageSizeInBytes,
+		AccountID:        event.AccountID,
+		Region:           event.Region,
+	}
+
+	switch format {
+	case "", "text":
+		return fmt.Sprintf("repository=%s tags=%s digest=%s imagePushedAt=%s imageSizeInBytes=%d accountId=%s region=%s",
+			p.Repository, strings.Join(p.Tags, ","), p.Digest, p.ImagePushedAt, p.ImageSizeInBytes, p.AccountID, p.Region), nil
+	case "json":
+		b, err := json.Marshal(p)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return "", fmt.Errorf("notifier: unknown format %q", format)
+	}
+}
@@ -0,0 +1,43 @@
+// Package notifier defines the notification sink interface used to tell the
+// outside world about a new matching ECR image, along with a handful of
+// built-in sinks (HTTP webhook, exec, file, SNS).
+package notifier
+
+import (
+	"context"
+	"time"
+)
+
+// ImageEvent describes a matched ECR image at the moment a notification is
+// fired. It carries enough information for a downstream system (Argo, Flux,
+// Jenkins, a shell script, ...) to act without calling back into ECR.
+type ImageEvent struct {
+	AccountID        string
+	Region           string
+	Repository       string
+	Tags             []string
+	Digest           string
+	ImagePushedAt    time.Time
+	ImageSizeInBytes int64
+}
+
+// Notifier fires a single notification for an ImageEvent. Implementations
+// should treat ctx cancellation as a reason to abort in-flight work and
+// return its error.
+type Notifier interface {
+	Notify(ctx context.Context, event ImageEvent) error
+}
+
+// Multi fans a single Notify call out to every Notifier in ns, returning the
+// first error encountered after attempting all of them.
+type Multi []Notifier
+
+func (ns Multi) Notify(ctx context.Context, event ImageEvent) error {
+	var firstErr error
+	for _, n := range ns {
+		if err := n.Notify(ctx, event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
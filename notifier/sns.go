@@ -0,0 +1,35 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+)
+
+// SNS publishes the JSON-formatted event to an SNS topic, letting
+// subscribers (SQS queues, Lambdas, email/SMS) fan out from there.
+type SNS struct {
+	TopicARN string
+	Client   snsiface.SNSAPI
+}
+
+// NewSNS returns an SNS notifier publishing to topicARN using awsSession.
+func NewSNS(awsSession *session.Session, topicARN string) *SNS {
+	return &SNS{TopicARN: topicARN, Client: sns.New(awsSession)}
+}
+
+func (s *SNS) Notify(ctx context.Context, event ImageEvent) error {
+	message, err := Format(event, "json")
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Client.PublishWithContext(ctx, &sns.PublishInput{
+		TopicArn: aws.String(s.TopicARN),
+		Message:  aws.String(message),
+	})
+	return err
+}
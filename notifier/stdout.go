@@ -0,0 +1,19 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Stdout writes the event's tag list, comma-separated, to an io.Writer.
+// It's the default sink when no NOTIFY_* destination is configured.
+type Stdout struct {
+	Writer io.Writer
+}
+
+func (s *Stdout) Notify(ctx context.Context, event ImageEvent) error {
+	_, err := fmt.Fprint(s.Writer, strings.Join(event.Tags, ","))
+	return err
+}
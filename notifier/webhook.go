@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Webhook POSTs the event to a URL. The body is always JSON regardless of
+// the configured NOTIFY_FORMAT, since webhook consumers expect structured
+// bodies; Format only governs the text-oriented sinks (exec, file).
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhook returns a Webhook notifier posting to url with http.DefaultClient.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{URL: url, Client: http.DefaultClient}
+}
+
+func (w *Webhook) Notify(ctx context.Context, event ImageEvent) error {
+	body, err := Format(event, "json")
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notifier: webhook %s returned status %s", w.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+// Package state persists the last-seen digest for each watched tag across
+// ecr-watch restarts, so a restart in --watch mode doesn't refire a
+// notification for every tag it already knew about.
+package state
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// TagDigests maps a matched tag to the digest it pointed to as of the last
+// poll.
+type TagDigests map[string]string
+
+// Load reads a TagDigests map from path. A missing file is not an error; it
+// simply yields an empty baseline, matching first-run behavior.
+func Load(path string) (TagDigests, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return TagDigests{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tagDigests := TagDigests{}
+	if err := json.Unmarshal(data, &tagDigests); err != nil {
+		return nil, err
+	}
+	return tagDigests, nil
+}
+
+// Save writes tagDigests to path as JSON.
+func Save(path string, tagDigests TagDigests) error {
+	data, err := json.Marshal(tagDigests)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,40 @@
+package watcher
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// backoffBase is the starting delay for the retry policy's exponential
+// backoff.
+const backoffBase = time.Second
+
+// fullJitterBackoff returns a full-jitter backoff delay for the given retry
+// attempt (0-indexed), doubling from backoffBase and capping at cap.
+func fullJitterBackoff(attempt int, cap time.Duration) time.Duration {
+	d := backoffBase
+	for i := 0; i < attempt && d < cap; i++ {
+		d *= 2
+	}
+	if d > cap {
+		d = cap
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// sleepContext sleeps for d, returning false early if ctx is cancelled
+// first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,53 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FileConfig is the shape of the document pointed to by CONFIG_FILE, letting
+// a single ecr-watch process watch many repositories at once.
+type FileConfig struct {
+	Repositories []Repository `yaml:"repositories" json:"repositories"`
+}
+
+// LoadFileConfig reads and parses a FileConfig from path, choosing YAML or
+// JSON based on its extension (.yaml/.yml vs everything else).
+func LoadFileConfig(path string) (*FileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileConfig := &FileConfig{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, fileConfig); err != nil {
+			return nil, err
+		}
+	default:
+		if err := json.Unmarshal(data, fileConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(fileConfig.Repositories) == 0 {
+		return nil, fmt.Errorf("watcher: %s declares no repositories", path)
+	}
+
+	for i, repo := range fileConfig.Repositories {
+		if repo.Interval <= 0 {
+			return nil, fmt.Errorf("watcher: %s: repository %q has no positive interval", path, repo.Name)
+		}
+		if repo.TagPattern == "" {
+			fileConfig.Repositories[i].TagPattern = defaultTagPattern
+		}
+	}
+
+	return fileConfig, nil
+}
@@ -0,0 +1,36 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoadFileConfigDefaultsMissingTagPattern(t *testing.T) {
+	path := writeConfig(t, "config.json", `{"repositories":[{"name":"repo","interval":"30s"}]}`)
+
+	fileConfig, err := LoadFileConfig(path)
+	if err != nil {
+		t.Fatalf("LoadFileConfig: %v", err)
+	}
+	if got := fileConfig.Repositories[0].TagPattern; got != defaultTagPattern {
+		t.Fatalf("TagPattern = %q, want default %q", got, defaultTagPattern)
+	}
+}
+
+func TestLoadFileConfigRejectsZeroInterval(t *testing.T) {
+	path := writeConfig(t, "config.json", `{"repositories":[{"name":"repo","tag_pattern":"^latest$"}]}`)
+
+	if _, err := LoadFileConfig(path); err == nil {
+		t.Fatal("expected an error for a repository with no interval, got nil")
+	}
+}
@@ -0,0 +1,61 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration that unmarshals from either a Go duration
+// string (e.g. "30s", "5m") or a raw nanosecond count, in both YAML and
+// JSON config files. A bare time.Duration only accepts the latter from
+// JSON, which rejects the human-friendly form CONFIG_FILE examples use.
+type Duration time.Duration
+
+// String implements fmt.Stringer so a Duration logs the same way a
+// time.Duration does.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var asString string
+	if err := json.Unmarshal(data, &asString); err == nil {
+		parsed, err := time.ParseDuration(asString)
+		if err != nil {
+			return fmt.Errorf("watcher: invalid duration %q: %w", asString, err)
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+
+	var asNanoseconds int64
+	if err := json.Unmarshal(data, &asNanoseconds); err != nil {
+		return fmt.Errorf("watcher: duration must be a string (e.g. \"30s\") or a nanosecond count: %w", err)
+	}
+	*d = Duration(asNanoseconds)
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler (gopkg.in/yaml.v2).
+// The nanosecond form is tried first because yaml.v2 also decodes a bare
+// number into a string, which time.ParseDuration would reject.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var asNanoseconds int64
+	if err := unmarshal(&asNanoseconds); err == nil {
+		*d = Duration(asNanoseconds)
+		return nil
+	}
+
+	var asString string
+	if err := unmarshal(&asString); err != nil {
+		return fmt.Errorf("watcher: duration must be a string (e.g. \"30s\") or a nanosecond count: %w", err)
+	}
+	parsed, err := time.ParseDuration(asString)
+	if err != nil {
+		return fmt.Errorf("watcher: invalid duration %q: %w", asString, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
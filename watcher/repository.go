@@ -0,0 +1,21 @@
+package watcher
+
+// defaultTagPattern is applied to a CONFIG_FILE repository that omits
+// tag_pattern, matching the TAG_PATTERN default for the single-repository
+// REPOSITORY env var path. Without it, an empty TagPattern compiles to a
+// regexp that matches every tag.
+const defaultTagPattern = "^latest$"
+
+// Repository identifies a single ECR repository to poll, optionally in
+// another AWS account/registry than the one ecr-watch is authenticated
+// against.
+type Repository struct {
+	RegistryID      string   `yaml:"registry_id" json:"registry_id"`
+	Name            string   `yaml:"name" json:"name"`
+	TagPattern      string   `yaml:"tag_pattern" json:"tag_pattern"`
+	Interval        Duration `yaml:"interval" json:"interval"`
+	StateFile       string   `yaml:"state_file" json:"state_file"`
+	TagStrategy     string   `yaml:"tag_strategy" json:"tag_strategy"`
+	SemverPattern   string   `yaml:"semver_pattern" json:"semver_pattern"`
+	AllowPrerelease bool     `yaml:"allow_prerelease" json:"allow_prerelease"`
+}
@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/buth/ecr-watch/metrics"
+)
+
+// isFatal reports whether err is an auth/permission error that retrying
+// cannot fix.
+func isFatal(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch awsErr.Code() {
+	case "AccessDeniedException", "RepositoryNotFoundException":
+		return true
+	default:
+		return false
+	}
+}
+
+// is5xxError reports whether err is an ECR request failure with a server
+// (5xx) status code.
+func is5xxError(err error) bool {
+	requestFailure, ok := err.(awserr.RequestFailure)
+	return ok && requestFailure.StatusCode() >= 500
+}
+
+// retry calls fn, retrying with full-jitter exponential backoff (capped at
+// repo.Interval) on throttling and 5xx errors. It gives up and returns the
+// error immediately on a fatal (auth/permission) error or context
+// cancellation.
+func (w *Watcher) retry(ctx context.Context, repo Repository, operation string, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if isFatal(err) {
+			return err
+		}
+		if !isThrottled(err) && !is5xxError(err) {
+			return err
+		}
+		if isThrottled(err) {
+			metrics.APIThrottledTotal.WithLabelValues(repo.Name).Inc()
+		}
+
+		delay := fullJitterBackoff(attempt, time.Duration(repo.Interval))
+		w.Logger.Printf("%s: %s failed (%v), retrying in %s", repo.Name, operation, err, delay)
+		if !sleepContext(ctx, delay) {
+			return fmt.Errorf("watcher: %s cancelled: %w", operation, ctx.Err())
+		}
+	}
+}
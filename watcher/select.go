@@ -0,0 +1,107 @@
+package watcher
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// Tag selection strategies, set via Repository.TagStrategy.
+const (
+	// TagStrategyPushedAt picks the image whose tag was pushed most
+	// recently. This is ecr-watch's original behavior and is subject to
+	// the well-known ECR gotcha where re-pushing an older tag makes it
+	// look newest.
+	TagStrategyPushedAt = "pushed_at"
+	// TagStrategySemver parses matched tags as semver and picks the
+	// highest version.
+	TagStrategySemver = "semver"
+	// TagStrategySemverPattern is like TagStrategySemver but additionally
+	// restricts candidates to those satisfying Repository.SemverPattern.
+	TagStrategySemverPattern = "semver_pattern"
+)
+
+// candidate is a single matched tag under consideration for "latest".
+type candidate struct {
+	tag         string
+	imageDetail *ecr.ImageDetail
+	version     *semverVersion
+}
+
+// selectLatest picks the single "latest" image among imageDetails according
+// to repo's configured strategy, returning the winning tag and its
+// ImageDetail. It returns a nil ImageDetail if nothing matched.
+func selectLatest(repo Repository, imageDetails []*ecr.ImageDetail, tagRegexp *regexp.Regexp) (string, *ecr.ImageDetail, error) {
+	switch repo.TagStrategy {
+	case "", TagStrategyPushedAt:
+		return selectByPushedAt(imageDetails, tagRegexp)
+	case TagStrategySemver, TagStrategySemverPattern:
+		return selectBySemver(repo, imageDetails, tagRegexp)
+	default:
+		return "", nil, fmt.Errorf("watcher: unknown tag strategy %q", repo.TagStrategy)
+	}
+}
+
+func selectByPushedAt(imageDetails []*ecr.ImageDetail, tagRegexp *regexp.Regexp) (string, *ecr.ImageDetail, error) {
+	var latest *ecr.ImageDetail
+	for _, imageDetail := range imageDetails {
+		if latest == nil || aws.TimeValue(imageDetail.ImagePushedAt).After(aws.TimeValue(latest.ImagePushedAt)) {
+			latest = imageDetail
+		}
+	}
+	if latest == nil {
+		return "", nil, nil
+	}
+
+	tag := ""
+	for _, t := range aws.StringValueSlice(latest.ImageTags) {
+		if tagRegexp.MatchString(t) {
+			tag = t
+			break
+		}
+	}
+	return tag, latest, nil
+}
+
+func selectBySemver(repo Repository, imageDetails []*ecr.ImageDetail, tagRegexp *regexp.Regexp) (string, *ecr.ImageDetail, error) {
+	var constraints []semverConstraint
+	if repo.TagStrategy == TagStrategySemverPattern {
+		parsed, err := parseSemverConstraints(repo.SemverPattern)
+		if err != nil {
+			return "", nil, err
+		}
+		constraints = parsed
+	}
+
+	var best *candidate
+	for _, imageDetail := range imageDetails {
+		for _, tagValue := range imageDetail.ImageTags {
+			tag := aws.StringValue(tagValue)
+			if !tagRegexp.MatchString(tag) {
+				continue
+			}
+
+			version, err := parseSemver(tag)
+			if err != nil {
+				continue
+			}
+			if version.prerelease != "" && !repo.AllowPrerelease {
+				continue
+			}
+			if constraints != nil && !satisfiesSemverConstraints(version, constraints) {
+				continue
+			}
+
+			if best == nil || version.compare(best.version) > 0 {
+				best = &candidate{tag: tag, imageDetail: imageDetail, version: version}
+			}
+		}
+	}
+
+	if best == nil {
+		return "", nil, nil
+	}
+	return best.tag, best.imageDetail, nil
+}
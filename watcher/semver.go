@@ -0,0 +1,167 @@
+package watcher
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// semverPattern matches an optional leading "v", MAJOR.MINOR.PATCH, an
+// optional -prerelease, and an optional +build, in the style of
+// Masterminds/semver.
+var semverPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z-.]+))?(?:\+([0-9A-Za-z-.]+))?$`)
+
+// semverVersion is a parsed MAJOR.MINOR.PATCH version. Build metadata is
+// kept only for display; it never participates in comparison or ordering.
+type semverVersion struct {
+	major, minor, patch uint64
+	prerelease          string
+	original            string
+}
+
+// parseSemver parses tag as a semver version, returning an error if it
+// doesn't conform.
+func parseSemver(tag string) (*semverVersion, error) {
+	groups := semverPattern.FindStringSubmatch(tag)
+	if groups == nil {
+		return nil, fmt.Errorf("watcher: %q is not a semver tag", tag)
+	}
+
+	major, _ := strconv.ParseUint(groups[1], 10, 64)
+	minor, _ := strconv.ParseUint(groups[2], 10, 64)
+	patch, _ := strconv.ParseUint(groups[3], 10, 64)
+
+	return &semverVersion{
+		major:      major,
+		minor:      minor,
+		patch:      patch,
+		prerelease: groups[4],
+		original:   tag,
+	}, nil
+}
+
+// compare returns -1, 0, or 1 as v is less than, equal to, or greater than
+// other. MAJOR.MINOR.PATCH are compared numerically, and a version with a
+// prerelease is lower than the same version without one. Two prereleases of
+// the same version are ordered by plain string comparison of the whole
+// prerelease string, not identifier by identifier (so "rc.10" < "rc.2").
+func (v *semverVersion) compare(other *semverVersion) int {
+	for _, pair := range [][2]uint64{{v.major, other.major}, {v.minor, other.minor}, {v.patch, other.patch}} {
+		if pair[0] != pair[1] {
+			if pair[0] < pair[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	case v.prerelease < other.prerelease:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// semverConstraint is a single "<op><version>" comparator, e.g. ">=2.0.0".
+type semverConstraint struct {
+	operator string
+	version  *semverVersion
+}
+
+var constraintPattern = regexp.MustCompile(`^(>=|<=|==|!=|>|<|=)?\s*(.+)$`)
+
+// parseSemverConstraints parses a space-separated list of comparators
+// (">=2.0.0 <3.0.0") or a tilde range ("~1.2"), all of which must hold for
+// a version to satisfy the constraint.
+func parseSemverConstraints(s string) ([]semverConstraint, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("watcher: empty semver constraint")
+	}
+
+	if strings.HasPrefix(fields[0], "~") && len(fields) == 1 {
+		return parseTildeConstraint(strings.TrimPrefix(fields[0], "~"))
+	}
+
+	constraints := make([]semverConstraint, 0, len(fields))
+	for _, field := range fields {
+		groups := constraintPattern.FindStringSubmatch(field)
+		if groups == nil {
+			return nil, fmt.Errorf("watcher: invalid semver constraint %q", field)
+		}
+
+		operator := groups[1]
+		if operator == "" {
+			operator = "="
+		}
+
+		version, err := parseSemver(padVersion(groups[2]))
+		if err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, semverConstraint{operator: operator, version: version})
+	}
+	return constraints, nil
+}
+
+// parseTildeConstraint expands "~1.2" into >=1.2.0 <1.3.0, and "~1.2.3" into
+// >=1.2.3 <1.3.0.
+func parseTildeConstraint(s string) ([]semverConstraint, error) {
+	lower, err := parseSemver(padVersion(s))
+	if err != nil {
+		return nil, err
+	}
+
+	upper := &semverVersion{major: lower.major, minor: lower.minor + 1, patch: 0}
+	return []semverConstraint{
+		{operator: ">=", version: lower},
+		{operator: "<", version: upper},
+	}, nil
+}
+
+// padVersion fills in missing MINOR/PATCH components ("1.2" -> "1.2.0") so
+// partial versions are accepted in constraints.
+func padVersion(s string) string {
+	switch strings.Count(s, ".") {
+	case 0:
+		return s + ".0.0"
+	case 1:
+		return s + ".0"
+	default:
+		return s
+	}
+}
+
+// satisfiesSemverConstraints reports whether v meets every comparator in constraints.
+func satisfiesSemverConstraints(v *semverVersion, constraints []semverConstraint) bool {
+	for _, constraint := range constraints {
+		cmp := v.compare(constraint.version)
+		var ok bool
+		switch constraint.operator {
+		case "=", "==":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,285 @@
+// Package watcher polls one or more ECR repositories for matching tags and
+// fires notifications through a shared notifier.Notifier when they change.
+package watcher
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/buth/ecr-watch/metrics"
+	"github.com/buth/ecr-watch/notifier"
+	"github.com/buth/ecr-watch/state"
+)
+
+// isThrottled reports whether err is an ECR throttling error.
+func isThrottled(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch awsErr.Code() {
+	case "ThrottlingException", "ProvisionedThroughputExceededException":
+		return true
+	default:
+		return false
+	}
+}
+
+// Watcher polls repositories using a shared ECR client and notifier. A
+// single Watcher can run many Repositories concurrently via Run, each in
+// its own goroutine.
+type Watcher struct {
+	ECRClient ecriface.ECRAPI
+	Notifier  notifier.Notifier
+	AccountID string
+	Region    string
+	Watch     bool
+	Logger    *log.Logger
+}
+
+// Run polls repo until it either reports a change (one-shot mode) or ctx is
+// cancelled (watch mode never returns on its own before that). A shutdown
+// triggered by ctx cancellation is reported as a nil error: an in-flight AWS
+// call aborted by ctx surfaces as a request-cancelled error, not ctx.Err()
+// itself, so callers can't tell a clean shutdown from a real failure without
+// this check.
+func (w *Watcher) Run(ctx context.Context, repo Repository) error {
+	tagRegexp, err := regexp.Compile(repo.TagPattern)
+	if err != nil {
+		return err
+	}
+
+	if w.Watch {
+		err = w.runWatch(ctx, repo, tagRegexp)
+	} else {
+		err = w.runOnce(ctx, repo, tagRegexp)
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// maxDescribeImageIDs is the most ImageIds a single DescribeImages call
+// accepts.
+const maxDescribeImageIDs = 100
+
+// matchingImageDetails lists and describes the images in repo whose tags
+// match tagRegexp, handling ListImages pagination, DescribeImages' 100-ID
+// limit per call, and retries with backoff on throttling/5xx errors.
+func (w *Watcher) matchingImageDetails(ctx context.Context, repo Repository, tagRegexp *regexp.Regexp) ([]*ecr.ImageDetail, error) {
+	start := time.Now()
+	imageDetails, err := w.doMatchingImageDetails(ctx, repo, tagRegexp)
+	metrics.PollDuration.WithLabelValues(repo.Name).Observe(time.Since(start).Seconds())
+
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	metrics.PollTotal.WithLabelValues(repo.Name, result).Inc()
+
+	return imageDetails, err
+}
+
+func (w *Watcher) doMatchingImageDetails(ctx context.Context, repo Repository, tagRegexp *regexp.Regexp) ([]*ecr.ImageDetail, error) {
+	var registryID *string
+	if repo.RegistryID != "" {
+		registryID = aws.String(repo.RegistryID)
+	}
+
+	imageIDs := []*ecr.ImageIdentifier{}
+	listImagesInput := &ecr.ListImagesInput{
+		RegistryId:     registryID,
+		RepositoryName: aws.String(repo.Name),
+	}
+
+	for {
+		var listImagesOutput *ecr.ListImagesOutput
+		err := w.retry(ctx, repo, "ListImages", func() error {
+			var err error
+			listImagesOutput, err = w.ECRClient.ListImagesWithContext(ctx, listImagesInput)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, imageID := range listImagesOutput.ImageIds {
+			if tag := aws.StringValue(imageID.ImageTag); tagRegexp.MatchString(tag) {
+				w.Logger.Printf("%s: matched tag: %s", repo.Name, tag)
+				imageIDs = append(imageIDs, imageID)
+			}
+		}
+
+		if nextToken := listImagesOutput.NextToken; nextToken != nil {
+			listImagesInput.NextToken = nextToken
+		} else {
+			break
+		}
+	}
+
+	imageDetails := []*ecr.ImageDetail{}
+	for i := 0; i < len(imageIDs); i += maxDescribeImageIDs {
+		end := i + maxDescribeImageIDs
+		if end > len(imageIDs) {
+			end = len(imageIDs)
+		}
+
+		describeImagesInput := &ecr.DescribeImagesInput{
+			RegistryId:     registryID,
+			RepositoryName: aws.String(repo.Name),
+			ImageIds:       imageIDs[i:end],
+		}
+
+		var describeImagesOutput *ecr.DescribeImagesOutput
+		err := w.retry(ctx, repo, "DescribeImages", func() error {
+			var err error
+			describeImagesOutput, err = w.ECRClient.DescribeImagesWithContext(ctx, describeImagesInput)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		imageDetails = append(imageDetails, describeImagesOutput.ImageDetails...)
+	}
+
+	return imageDetails, nil
+}
+
+// recordImageAge updates ecr_watch_image_age_seconds for tag of repo: the
+// selected tag in one-shot mode, each matched tag in watch mode.
+func (w *Watcher) recordImageAge(repo Repository, tag string, imageDetail *ecr.ImageDetail) {
+	if imageDetail == nil {
+		return
+	}
+	age := time.Since(aws.TimeValue(imageDetail.ImagePushedAt)).Seconds()
+	metrics.ImageAgeSeconds.WithLabelValues(repo.Name, tag).Set(age)
+}
+
+// event builds the notifier.ImageEvent for an ImageDetail of repo,
+// restricting the reported tag list to changedTags.
+func (w *Watcher) event(repo Repository, imageDetail *ecr.ImageDetail, changedTags []string) notifier.ImageEvent {
+	return notifier.ImageEvent{
+		AccountID:        w.AccountID,
+		Region:           w.Region,
+		Repository:       repo.Name,
+		Tags:             changedTags,
+		Digest:           aws.StringValue(imageDetail.ImageDigest),
+		ImagePushedAt:    aws.TimeValue(imageDetail.ImagePushedAt),
+		ImageSizeInBytes: aws.Int64Value(imageDetail.ImageSizeInBytes),
+	}
+}
+
+// runOnce polls until repo's TagStrategy selects a "latest" image whose
+// digest differs from the one seen on the previous poll, notifies once, and
+// returns. It also returns (with a nil error) if ctx is cancelled first.
+func (w *Watcher) runOnce(ctx context.Context, repo Repository, tagRegexp *regexp.Regexp) error {
+	previousDigest := ""
+	haveBaseline := false
+	for {
+		imageDetails, err := w.matchingImageDetails(ctx, repo, tagRegexp)
+		if err != nil {
+			return err
+		}
+
+		tag, imageDetail, err := selectLatest(repo, imageDetails, tagRegexp)
+		if err != nil {
+			return err
+		}
+
+		var digest string
+		if imageDetail != nil {
+			digest = aws.StringValue(imageDetail.ImageDigest)
+		}
+		w.recordImageAge(repo, tag, imageDetail)
+
+		if haveBaseline && digest != "" && digest != previousDigest {
+			w.Logger.Printf("%s: exiting", repo.Name)
+			metrics.LastChangeTimestamp.WithLabelValues(repo.Name).SetToCurrentTime()
+			event := w.event(repo, imageDetail, []string{tag})
+			return w.Notifier.Notify(ctx, event)
+		}
+
+		previousDigest = digest
+		haveBaseline = true
+		w.Logger.Printf("%s: latest matching tag is %q", repo.Name, tag)
+		w.Logger.Printf("%s: sleeping for %s", repo.Name, repo.Interval)
+		if !sleepContext(ctx, time.Duration(repo.Interval)) {
+			return nil
+		}
+	}
+}
+
+// runWatch polls repo until ctx is cancelled, tracking the last-seen digest
+// of every matched tag and firing a notification whenever any of them
+// changes. State is persisted to repo.StateFile (if set) so a restart
+// doesn't refire tags it already knew about.
+func (w *Watcher) runWatch(ctx context.Context, repo Repository, tagRegexp *regexp.Regexp) error {
+	tagDigests := state.TagDigests{}
+	if repo.StateFile != "" {
+		loaded, err := state.Load(repo.StateFile)
+		if err != nil {
+			return err
+		}
+		tagDigests = loaded
+	}
+
+	for {
+		imageDetails, err := w.matchingImageDetails(ctx, repo, tagRegexp)
+		if err != nil {
+			return err
+		}
+
+		for _, imageDetail := range imageDetails {
+			digest := aws.StringValue(imageDetail.ImageDigest)
+			changedTags := []string{}
+			for _, tagValue := range imageDetail.ImageTags {
+				tag := aws.StringValue(tagValue)
+				if !tagRegexp.MatchString(tag) {
+					continue
+				}
+				w.recordImageAge(repo, tag, imageDetail)
+
+				previousDigest, seen := tagDigests[tag]
+				if !seen {
+					tagDigests[tag] = digest
+					continue
+				}
+				if previousDigest != digest {
+					changedTags = append(changedTags, tag)
+				}
+			}
+
+			if len(changedTags) > 0 {
+				w.Logger.Printf("%s: tags changed: %s", repo.Name, strings.Join(changedTags, ","))
+				metrics.LastChangeTimestamp.WithLabelValues(repo.Name).SetToCurrentTime()
+				event := w.event(repo, imageDetail, changedTags)
+				if err := w.Notifier.Notify(ctx, event); err != nil {
+					w.Logger.Println(err)
+				} else {
+					for _, tag := range changedTags {
+						tagDigests[tag] = digest
+					}
+				}
+			}
+		}
+
+		if repo.StateFile != "" {
+			if err := state.Save(repo.StateFile, tagDigests); err != nil {
+				w.Logger.Println(err)
+			}
+		}
+
+		w.Logger.Printf("%s: sleeping for %s", repo.Name, repo.Interval)
+		if !sleepContext(ctx, time.Duration(repo.Interval)) {
+			return nil
+		}
+	}
+}
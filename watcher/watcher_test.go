@@ -0,0 +1,256 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+	"github.com/buth/ecr-watch/metrics"
+	"github.com/buth/ecr-watch/notifier"
+	"github.com/buth/ecr-watch/state"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeECRClient is a minimal ecriface.ECRAPI for testing the polling,
+// pagination, chunking, and retry logic without talking to AWS. Embedding
+// the interface satisfies it; any method not overridden below panics if
+// called.
+type fakeECRClient struct {
+	ecriface.ECRAPI
+
+	listPages         [][]*ecr.ImageIdentifier
+	listCalls         int
+	throttleListCalls int
+
+	describeCalls [][]*ecr.ImageIdentifier
+}
+
+func (f *fakeECRClient) ListImagesWithContext(ctx aws.Context, in *ecr.ListImagesInput, opts ...request.Option) (*ecr.ListImagesOutput, error) {
+	if f.throttleListCalls > 0 {
+		f.throttleListCalls--
+		return nil, awserr.New("ThrottlingException", "rate exceeded", nil)
+	}
+
+	page := f.listPages[f.listCalls]
+	f.listCalls++
+
+	output := &ecr.ListImagesOutput{ImageIds: page}
+	if f.listCalls < len(f.listPages) {
+		output.NextToken = aws.String(fmt.Sprintf("token-%d", f.listCalls))
+	}
+	return output, nil
+}
+
+func (f *fakeECRClient) DescribeImagesWithContext(ctx aws.Context, in *ecr.DescribeImagesInput, opts ...request.Option) (*ecr.DescribeImagesOutput, error) {
+	f.describeCalls = append(f.describeCalls, in.ImageIds)
+
+	imageDetails := make([]*ecr.ImageDetail, len(in.ImageIds))
+	for i, imageID := range in.ImageIds {
+		imageDetails[i] = &ecr.ImageDetail{
+			ImageDigest:   imageID.ImageDigest,
+			ImageTags:     []*string{imageID.ImageTag},
+			ImagePushedAt: aws.Time(time.Now()),
+		}
+	}
+	return &ecr.DescribeImagesOutput{ImageDetails: imageDetails}, nil
+}
+
+func testWatcher(ecrClient ecriface.ECRAPI) *Watcher {
+	return &Watcher{
+		ECRClient: ecrClient,
+		Logger:    log.New(io.Discard, "", 0),
+	}
+}
+
+func imageIdentifier(tag, digest string) *ecr.ImageIdentifier {
+	return &ecr.ImageIdentifier{ImageTag: aws.String(tag), ImageDigest: aws.String(digest)}
+}
+
+func TestMatchingImageDetailsPagination(t *testing.T) {
+	fake := &fakeECRClient{
+		listPages: [][]*ecr.ImageIdentifier{
+			{imageIdentifier("v1.0.0", "sha256:1"), imageIdentifier("other", "sha256:x")},
+			{imageIdentifier("v1.1.0", "sha256:2")},
+		},
+	}
+	w := testWatcher(fake)
+
+	tagRegexp := regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
+	imageDetails, err := w.matchingImageDetails(context.Background(), Repository{Name: "repo"}, tagRegexp)
+	if err != nil {
+		t.Fatalf("matchingImageDetails: %v", err)
+	}
+	if len(imageDetails) != 2 {
+		t.Fatalf("got %d image details, want 2 (non-matching tag should be excluded, both pages should be consumed)", len(imageDetails))
+	}
+}
+
+func TestMatchingImageDetailsRetriesOnThrottling(t *testing.T) {
+	fake := &fakeECRClient{
+		throttleListCalls: 2,
+		listPages: [][]*ecr.ImageIdentifier{
+			{imageIdentifier("v1.0.0", "sha256:1")},
+		},
+	}
+	w := testWatcher(fake)
+
+	repo := Repository{Name: "TestMatchingImageDetailsRetriesOnThrottling", Interval: Duration(10 * time.Millisecond)}
+	tagRegexp := regexp.MustCompile(`^v`)
+	imageDetails, err := w.matchingImageDetails(context.Background(), repo, tagRegexp)
+	if err != nil {
+		t.Fatalf("matchingImageDetails: %v", err)
+	}
+	if len(imageDetails) != 1 {
+		t.Fatalf("got %d image details, want 1", len(imageDetails))
+	}
+
+	if got := testutil.ToFloat64(metrics.APIThrottledTotal.WithLabelValues(repo.Name)); got != 2 {
+		t.Fatalf("APIThrottledTotal = %v, want 2 (one per throttled ListImages call)", got)
+	}
+}
+
+func TestMatchingImageDetailsChunksDescribeImages(t *testing.T) {
+	imageIDs := make([]*ecr.ImageIdentifier, 0, 250)
+	for i := 0; i < 250; i++ {
+		imageIDs = append(imageIDs, imageIdentifier(fmt.Sprintf("v1.0.%d", i), fmt.Sprintf("sha256:%d", i)))
+	}
+	fake := &fakeECRClient{listPages: [][]*ecr.ImageIdentifier{imageIDs}}
+	w := testWatcher(fake)
+
+	tagRegexp := regexp.MustCompile(`^v`)
+	imageDetails, err := w.matchingImageDetails(context.Background(), Repository{Name: "repo"}, tagRegexp)
+	if err != nil {
+		t.Fatalf("matchingImageDetails: %v", err)
+	}
+	if len(imageDetails) != 250 {
+		t.Fatalf("got %d image details, want 250", len(imageDetails))
+	}
+
+	if len(fake.describeCalls) != 3 {
+		t.Fatalf("got %d DescribeImages calls, want 3 (250 ids chunked at 100 per call)", len(fake.describeCalls))
+	}
+	for _, call := range fake.describeCalls {
+		if len(call) > maxDescribeImageIDs {
+			t.Fatalf("DescribeImages call with %d ids exceeds the %d-id limit", len(call), maxDescribeImageIDs)
+		}
+	}
+}
+
+func TestMatchingImageDetailsFailsFatalOnAccessDenied(t *testing.T) {
+	fake := &fakeECRClient{}
+	w := testWatcher(fake)
+	w.ECRClient = &fakeAccessDeniedClient{fakeECRClient: fake}
+
+	tagRegexp := regexp.MustCompile(`^v`)
+	_, err := w.matchingImageDetails(context.Background(), Repository{Name: "repo", Interval: Duration(time.Millisecond)}, tagRegexp)
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+	if !isFatal(err) {
+		t.Fatalf("expected a fatal AccessDeniedException, got %v", err)
+	}
+}
+
+type fakeAccessDeniedClient struct {
+	*fakeECRClient
+}
+
+func (f *fakeAccessDeniedClient) ListImagesWithContext(ctx aws.Context, in *ecr.ListImagesInput, opts ...request.Option) (*ecr.ListImagesOutput, error) {
+	return nil, awserr.New("AccessDeniedException", "not authorized", nil)
+}
+
+// sequencedECRClient serves a single tag whose digest is digests[poll] on
+// the poll'th call, so a test can drive runWatch through successive polls
+// that each see a different digest.
+type sequencedECRClient struct {
+	ecriface.ECRAPI
+
+	tag     string
+	digests []string
+	poll    int
+}
+
+func (f *sequencedECRClient) ListImagesWithContext(ctx aws.Context, in *ecr.ListImagesInput, opts ...request.Option) (*ecr.ListImagesOutput, error) {
+	return &ecr.ListImagesOutput{ImageIds: []*ecr.ImageIdentifier{imageIdentifier(f.tag, f.digests[f.poll])}}, nil
+}
+
+func (f *sequencedECRClient) DescribeImagesWithContext(ctx aws.Context, in *ecr.DescribeImagesInput, opts ...request.Option) (*ecr.DescribeImagesOutput, error) {
+	digest := f.digests[f.poll]
+	f.poll++
+	return &ecr.DescribeImagesOutput{ImageDetails: []*ecr.ImageDetail{{
+		ImageDigest:   aws.String(digest),
+		ImageTags:     []*string{aws.String(f.tag)},
+		ImagePushedAt: aws.Time(time.Now()),
+	}}}, nil
+}
+
+// fakeCancelledClient simulates an in-flight AWS call aborted by context
+// cancellation, which surfaces as a RequestCanceled awserr.Error rather than
+// ctx.Err() itself.
+type fakeCancelledClient struct {
+	ecriface.ECRAPI
+}
+
+func (f *fakeCancelledClient) ListImagesWithContext(ctx aws.Context, in *ecr.ListImagesInput, opts ...request.Option) (*ecr.ListImagesOutput, error) {
+	return nil, awserr.New("RequestCanceled", "request context canceled", ctx.Err())
+}
+
+func TestRunReturnsNilOnContextCancellation(t *testing.T) {
+	w := testWatcher(&fakeCancelledClient{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	repo := Repository{Name: "repo", TagPattern: "^latest$", Interval: Duration(time.Millisecond)}
+	if err := w.Run(ctx, repo); err != nil {
+		t.Fatalf("Run: got %v, want nil (ctx was cancelled, not a real failure)", err)
+	}
+}
+
+// erroringNotifier always fails, as if a webhook/exec/SNS sink were down.
+type erroringNotifier struct{}
+
+func (erroringNotifier) Notify(ctx context.Context, event notifier.ImageEvent) error {
+	return fmt.Errorf("notify: sink unavailable")
+}
+
+func TestRunWatchDoesNotAdvanceStateOnFailedNotify(t *testing.T) {
+	fake := &sequencedECRClient{tag: "latest", digests: []string{
+		"sha256:1", "sha256:2", "sha256:2", "sha256:2", "sha256:2", "sha256:2",
+	}}
+	w := testWatcher(fake)
+	w.Notifier = erroringNotifier{}
+
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	repo := Repository{Name: "repo", TagPattern: "^latest$", Interval: Duration(time.Millisecond), StateFile: stateFile}
+	tagRegexp := regexp.MustCompile(repo.TagPattern)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for fake.poll < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	if err := w.runWatch(ctx, repo, tagRegexp); err != nil {
+		t.Fatalf("runWatch: %v", err)
+	}
+
+	tagDigests, err := state.Load(stateFile)
+	if err != nil {
+		t.Fatalf("state.Load: %v", err)
+	}
+	if got := tagDigests["latest"]; got != "sha256:1" {
+		t.Fatalf("tagDigests[latest] = %q, want %q (unchanged after failed Notify)", got, "sha256:1")
+	}
+}